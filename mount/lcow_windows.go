@@ -0,0 +1,103 @@
+// +build windows
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TranslateToLCOW converts a Windows mount source (a drive-letter path, a
+// UNC path, or a '\\?\Volume{GUID}' path) into the 9p path a WSL2-based
+// LCOW guest sees it at, returning a Mount whose Type is "bind" and whose
+// Source is the translated guest path.
+func TranslateToLCOW(m Mount) (Mount, error) {
+	source := m.Source
+
+	if strings.HasPrefix(source, `\\?\Volume{`) {
+		driveSource, err := driveLetterMountPoint(source)
+		if err != nil {
+			return Mount{}, err
+		}
+		source = driveSource
+	}
+
+	lcowPath, err := windowsPathToLCOWPath(source)
+	if err != nil {
+		return Mount{}, err
+	}
+
+	return Mount{
+		Type:    "bind",
+		Source:  lcowPath,
+		Options: m.Options,
+	}, nil
+}
+
+// driveLetterMountPoint resolves volumePath (in format '\\?\Volume{GUID}')
+// to one of its current drive-letter mount points (a drive root such as
+// "D:\", not a mounted folder), since a volume GUID has no meaning inside
+// the guest.
+func driveLetterMountPoint(volumePath string) (string, error) {
+	mountPoints, err := listVolumeMountPoints(volumePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed calling listVolumeMountPoints('%s')", volumePath)
+	}
+
+	for _, mountPoint := range mountPoints {
+		if isDriveLetterPath(mountPoint) && len(strings.TrimSuffix(mountPoint, `\`)) == 2 {
+			return mountPoint, nil
+		}
+	}
+
+	return "", errors.Errorf("no drive-letter mount point found for volume %s", volumePath)
+}
+
+// isDriveLetterPath reports whether path begins with a drive letter, e.g.
+// "C:\" or "c:\foo".
+func isDriveLetterPath(path string) bool {
+	if len(path) < 2 || path[1] != ':' {
+		return false
+	}
+	return (path[0] >= 'a' && path[0] <= 'z') || (path[0] >= 'A' && path[0] <= 'Z')
+}
+
+// windowsPathToLCOWPath translates an absolute drive-letter or UNC Windows
+// path into the 9p path under which a WSL2 LCOW guest mounts it:
+// `X:\a\b` -> `/mnt/x/a/b` and `\\server\share\p` -> `/mnt/unc/server/share/p`.
+// Relative paths are rejected, since they have no meaning to the guest.
+func windowsPathToLCOWPath(path string) (string, error) {
+	if isDriveLetterPath(path) && len(path) > 2 && (path[2] == '\\' || path[2] == '/') {
+		drive := strings.ToLower(path[0:1])
+		rest := strings.TrimPrefix(strings.ReplaceAll(path[2:], `\`, "/"), "/")
+
+		if rest == "" {
+			return "/mnt/" + drive, nil
+		}
+		return "/mnt/" + drive + "/" + rest, nil
+	}
+
+	if strings.HasPrefix(path, `\\`) && !strings.HasPrefix(path, `\\?\`) {
+		unc := strings.TrimPrefix(strings.ReplaceAll(path, `\`, "/"), "//")
+		return "/mnt/unc/" + unc, nil
+	}
+
+	return "", errors.Errorf("unable to translate non-absolute Windows path %q to an LCOW path", path)
+}
@@ -0,0 +1,117 @@
+// +build windows
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWindowsPathToLCOWPath(t *testing.T) {
+	cases := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: `C:\foo`, want: "/mnt/c/foo"},
+		{path: `c:\a\b c`, want: "/mnt/c/a/b c"},
+		{path: `X:\`, want: "/mnt/x"},
+		{path: `\\server\share\p`, want: "/mnt/unc/server/share/p"},
+		{path: `foo\bar`, wantErr: true},
+		{path: `.\foo`, wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := windowsPathToLCOWPath(c.path)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("windowsPathToLCOWPath(%q) = %q, want error", c.path, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("windowsPathToLCOWPath(%q) failed: %v", c.path, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("windowsPathToLCOWPath(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestTranslateToLCOW(t *testing.T) {
+	m := Mount{Type: "windows-layer", Source: `D:\data`, Options: []string{"ro"}}
+
+	translated, err := TranslateToLCOW(m)
+	if err != nil {
+		t.Fatalf("TranslateToLCOW(%+v) failed: %v", m, err)
+	}
+
+	if translated.Type != "bind" {
+		t.Errorf("expected translated Type to be \"bind\", got %q", translated.Type)
+	}
+	if translated.Source != "/mnt/d/data" {
+		t.Errorf("expected translated Source to be \"/mnt/d/data\", got %q", translated.Source)
+	}
+	if len(translated.Options) != 1 || translated.Options[0] != "ro" {
+		t.Errorf("expected Options to be preserved, got %v", translated.Options)
+	}
+}
+
+func TestTranslateToLCOWVolumeGUIDSource(t *testing.T) {
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "mountpoint")
+	if err := os.Mkdir(target, 0); err != nil {
+		t.Fatalf("failed to create %s: %v", target, err)
+	}
+
+	volumeName, err := getVolumeNameForVolumeMountPoint(filepath.VolumeName(tempDir) + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("getVolumeNameForVolumeMountPoint(%s) failed: %v", tempDir, err)
+	}
+
+	if err := setVolumeMountPoint(target, volumeName); err != nil {
+		t.Skipf("setVolumeMountPoint(%s, %s) failed, this test requires admin privileges: %v", target, volumeName, err)
+	}
+	defer deleteVolumeMountPoint(target)
+
+	// volumeName is the volume backing tempDir, which is already mounted at
+	// whatever drive letter tempDir lives on, so resolving it should yield
+	// that drive letter rather than target itself.
+	translated, err := TranslateToLCOW(Mount{Type: "windows-layer", Source: volumeName})
+	if err != nil {
+		t.Fatalf("TranslateToLCOW(%s) failed: %v", volumeName, err)
+	}
+
+	want, err := windowsPathToLCOWPath(filepath.VolumeName(tempDir) + `\`)
+	if err != nil {
+		t.Fatalf("windowsPathToLCOWPath(%s) failed: %v", filepath.VolumeName(tempDir), err)
+	}
+	if translated.Source != want {
+		t.Errorf("TranslateToLCOW(%s) Source = %q, want %q", volumeName, translated.Source, want)
+	}
+}
+
+func TestTranslateToLCOWRejectsRelativeSource(t *testing.T) {
+	_, err := TranslateToLCOW(Mount{Source: `relative\path`})
+	if err == nil {
+		t.Errorf("expected an error translating a relative source")
+	}
+}
@@ -0,0 +1,55 @@
+// +build windows
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import "strings"
+
+// Mount mounts m at target. An "lcow" Mount has no host-side
+// representation to bind: TranslateToLCOW computes the 9p path the guest
+// will use it at, and Mount only validates that the translation succeeds.
+// Anything else binds directly: a '\\?\Volume{GUID}' Source is bound with
+// setVolumeMountPoint, anything else is treated as a host directory and
+// bound with setDirectoryJunction.
+func (m Mount) Mount(target string) error {
+	if m.Type == "lcow" {
+		_, err := TranslateToLCOW(m)
+		return err
+	}
+
+	if strings.HasPrefix(m.Source, "\\\\?\\Volume{") {
+		return setVolumeMountPoint(target, m.Source)
+	}
+
+	return setDirectoryJunction(target, m.Source)
+}
+
+// UnmountAll removes the mount point at target, whether it was bound as a
+// volume mount point or a directory junction.
+func UnmountAll(target string) error {
+	isJunction, err := isDirectoryJunction(target)
+	if err != nil {
+		return err
+	}
+
+	if isJunction {
+		return deleteDirectoryJunction(target)
+	}
+
+	return deleteVolumeMountPoint(target)
+}
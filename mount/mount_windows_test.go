@@ -0,0 +1,65 @@
+// +build windows
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestUnmountAllRoutesVolumeMountPointThroughVolumeTeardown guards against
+// UnmountAll misdetecting a real volume mount point as a directory junction:
+// both are IO_REPARSE_TAG_MOUNT_POINT reparse points, so a detection based
+// only on FILE_ATTRIBUTE_REPARSE_POINT would route every volume mount point
+// through deleteDirectoryJunction's raw FSCTL_DELETE_REPARSE_POINT instead of
+// DeleteVolumeMountPoint, leaving the volume manager's mount point table
+// stale.
+func TestUnmountAllRoutesVolumeMountPointThroughVolumeTeardown(t *testing.T) {
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "mountpoint")
+	if err := os.Mkdir(target, 0); err != nil {
+		t.Fatalf("failed to create %s: %v", target, err)
+	}
+
+	volumeName, err := getVolumeNameForVolumeMountPoint(filepath.VolumeName(tempDir) + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("getVolumeNameForVolumeMountPoint(%s) failed: %v", tempDir, err)
+	}
+
+	if err := setVolumeMountPoint(target, volumeName); err != nil {
+		t.Skipf("setVolumeMountPoint(%s, %s) failed, this test requires admin privileges: %v", target, volumeName, err)
+	}
+
+	if err := UnmountAll(target); err != nil {
+		t.Fatalf("UnmountAll(%s) failed: %v", target, err)
+	}
+
+	mountPoints, err := listVolumeMountPoints(volumeName)
+	if err != nil {
+		t.Fatalf("listVolumeMountPoints(%s) failed: %v", volumeName, err)
+	}
+
+	slashedTarget := filepath.Clean(target) + string(filepath.Separator)
+	for _, mountPoint := range mountPoints {
+		if mountPoint == slashedTarget {
+			t.Errorf("expected %s to no longer be a mount point of %s after UnmountAll, indicating it was torn down via deleteDirectoryJunction instead of DeleteVolumeMountPoint", target, volumeName)
+		}
+	}
+}
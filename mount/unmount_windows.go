@@ -0,0 +1,185 @@
+// +build windows
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"context"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	fsctlLockVolume     = 0x00090018
+	fsctlDismountVolume = 0x00090020
+)
+
+// defaultUnmountRetryDeadline is the RetryDeadline UnmountVolumeCtx uses
+// when UnmountOpts.RetryDeadline is zero.
+const defaultUnmountRetryDeadline = 5 * time.Second
+
+// UnmountOpts configures UnmountVolumeCtx's staged teardown of a volume
+// mount point.
+type UnmountOpts struct {
+	// ForceDismount opens the volume handle with exclusive read/write access,
+	// flushes it, and then issues FSCTL_LOCK_VOLUME and
+	// FSCTL_DISMOUNT_VOLUME, best-effort. Without it the volume is neither
+	// flushed nor locked. Off by default, since the exclusive open can itself
+	// fail while other handles are outstanding.
+	ForceDismount bool
+
+	// RetryDeadline bounds how long UnmountVolumeCtx retries, with
+	// exponential backoff, after a transient ERROR_SHARING_VIOLATION or
+	// ERROR_ACCESS_DENIED. Zero means defaultUnmountRetryDeadline (5s).
+	RetryDeadline time.Duration
+}
+
+// UnmountVolumeCtx removes the volume mount at target in stages: (1)
+// DeleteVolumeMountPoint; (2) open the underlying volume and, if
+// opts.ForceDismount is set, flush it and best-effort lock and dismount it.
+// A transient ERROR_SHARING_VIOLATION or ERROR_ACCESS_DENIED from either
+// stage — the common case when containerd is cleaning up after a crashed
+// shim and another process still holds a handle to a file under the mount
+// point or to the volume itself — is retried with exponential backoff until
+// opts.RetryDeadline elapses or ctx is done.
+func UnmountVolumeCtx(ctx context.Context, target string, opts UnmountOpts) error {
+	deadline := opts.RetryDeadline
+	if deadline == 0 {
+		deadline = defaultUnmountRetryDeadline
+	}
+	expiry := time.Now().Add(deadline)
+
+	var volumeName string
+	if err := retryUnmount(ctx, expiry, func() error {
+		vn, err := deleteVolumeMountPointOnce(target)
+		volumeName = vn
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return retryUnmount(ctx, expiry, func() error {
+		return closeAndDismountVolume(volumeName, opts.ForceDismount)
+	})
+}
+
+// retryUnmount calls fn until it succeeds, returns a non-retryable error, or
+// expiry/ctx is reached, backing off exponentially between attempts starting
+// at 10ms.
+func retryUnmount(ctx context.Context, expiry time.Time, fn func() error) error {
+	backoff := 10 * time.Millisecond
+	for {
+		err := fn()
+		if err == nil || !isRetryableUnmountError(err) || time.Now().After(expiry) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryableUnmountError reports whether err, however it was wrapped,
+// indicates a transient failure worth retrying.
+func isRetryableUnmountError(err error) bool {
+	cause := errors.Cause(err)
+	return cause == windows.ERROR_SHARING_VIOLATION || cause == windows.ERROR_ACCESS_DENIED
+}
+
+// deleteVolumeMountPointOnce calls DeleteVolumeMountPoint on targetPath and
+// returns the '\\?\Volume{GUID}' name it was bound to, for the subsequent
+// flush/dismount stage.
+func deleteVolumeMountPointOnce(targetPath string) (string, error) {
+	slashedTarget := filepath.Clean(targetPath) + string(filepath.Separator)
+
+	targetP, err := syscall.UTF16PtrFromString(slashedTarget)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to utf16-ise %s", slashedTarget)
+	}
+
+	volumeName, err := getVolumeNameForVolumeMountPoint(targetPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed calling getVolumeNameForVolumeMountPoint('%s')", targetPath)
+	}
+
+	if err := windows.DeleteVolumeMountPoint(targetP); err != nil {
+		return "", errors.Wrapf(err, "failed calling DeleteVolumeMountPoint('%s')", slashedTarget)
+	}
+
+	return volumeName, nil
+}
+
+// closeAndDismountVolume opens volumeName (in format '\\?\Volume{GUID}') and,
+// if forceDismount is set, flushes its buffers and best-effort locks and
+// dismounts it via FSCTL_LOCK_VOLUME/FSCTL_DISMOUNT_VOLUME. When forceDismount
+// is unset, the handle is opened with the minimal FILE_READ_ATTRIBUTES|
+// SYNCHRONIZE access and a share-all mode purely to confirm the volume is
+// still reachable, surfacing a sharing violation from a lingering handle for
+// UnmountVolumeCtx's caller to retry on.
+func closeAndDismountVolume(volumeName string, forceDismount bool) error {
+	// Strip the trailing slash off for CreateFile.
+	if volumeName[len(volumeName)-1] == filepath.Separator {
+		volumeName = volumeName[:len(volumeName)-1]
+	}
+
+	volumeNameP, err := syscall.UTF16PtrFromString(volumeName)
+	if err != nil {
+		return errors.Wrapf(err, "unable to utf16-ise %s", volumeName)
+	}
+
+	access := uint32(windows.FILE_READ_ATTRIBUTES | windows.SYNCHRONIZE)
+	shareMode := uint32(windows.FILE_SHARE_READ | windows.FILE_SHARE_WRITE | windows.FILE_SHARE_DELETE)
+	if forceDismount {
+		// FlushFileBuffers requires the handle to have been opened with
+		// GENERIC_WRITE access, and FSCTL_LOCK_VOLUME requires that we hold
+		// the only open handle.
+		access = windows.GENERIC_READ | windows.GENERIC_WRITE
+		shareMode = 0
+	}
+
+	volumeHandle, err := windows.CreateFile(volumeNameP, access, shareMode, nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return errors.Wrapf(err, "failed opening volumeHandle: %s", volumeName)
+	}
+	defer windows.CloseHandle(volumeHandle)
+
+	if !forceDismount {
+		return nil
+	}
+
+	if err := windows.FlushFileBuffers(volumeHandle); err != nil {
+		return errors.Wrapf(err, "failed flushing volumeHandle")
+	}
+
+	// Best-effort: failing to lock or dismount doesn't undo the
+	// DeleteVolumeMountPoint that already succeeded, so errors here are
+	// intentionally swallowed.
+	var bytesReturned uint32
+	windows.DeviceIoControl(volumeHandle, fsctlLockVolume, nil, 0, nil, 0, &bytesReturned, nil)
+	windows.DeviceIoControl(volumeHandle, fsctlDismountVolume, nil, 0, nil, 0, &bytesReturned, nil)
+
+	return nil
+}
@@ -0,0 +1,158 @@
+// +build windows
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// setUpHeldVolumeMount creates a mount point at target within tempDir and
+// returns a handle held open on the underlying volume, so that a later
+// force-dismount attempt (which requires exclusive access) observes a
+// sharing violation.
+func setUpHeldVolumeMount(t *testing.T, tempDir, target string) windows.Handle {
+	t.Helper()
+
+	volumeName, err := getVolumeNameForVolumeMountPoint(filepath.VolumeName(tempDir) + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("getVolumeNameForVolumeMountPoint(%s) failed: %v", tempDir, err)
+	}
+
+	if err := setVolumeMountPoint(target, volumeName); err != nil {
+		t.Skipf("setVolumeMountPoint(%s, %s) failed, this test requires admin privileges: %v", target, volumeName, err)
+	}
+
+	slashedVolume := volumeName[:len(volumeName)-1]
+	volumeP, err := syscall.UTF16PtrFromString(slashedVolume)
+	if err != nil {
+		t.Fatalf("unable to utf16-ise %s: %v", slashedVolume, err)
+	}
+
+	handle, err := windows.CreateFile(volumeP, windows.GENERIC_READ, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		t.Fatalf("failed opening held handle on %s: %v", volumeName, err)
+	}
+
+	return handle
+}
+
+// setUpHeldFileHandleInMount creates a mount point at target within tempDir
+// and returns a handle held open on a file underneath it, so that
+// deleteVolumeMountPointOnce's DeleteVolumeMountPoint call (stage 1) observes
+// a sharing violation, distinct from setUpHeldVolumeMount's handle on the
+// volume itself (stage 2).
+func setUpHeldFileHandleInMount(t *testing.T, tempDir, target string) windows.Handle {
+	t.Helper()
+
+	volumeName, err := getVolumeNameForVolumeMountPoint(filepath.VolumeName(tempDir) + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("getVolumeNameForVolumeMountPoint(%s) failed: %v", tempDir, err)
+	}
+
+	if err := setVolumeMountPoint(target, volumeName); err != nil {
+		t.Skipf("setVolumeMountPoint(%s, %s) failed, this test requires admin privileges: %v", target, volumeName, err)
+	}
+
+	filePath := filepath.Join(target, "held.txt")
+	filePathP, err := syscall.UTF16PtrFromString(filePath)
+	if err != nil {
+		t.Fatalf("unable to utf16-ise %s: %v", filePath, err)
+	}
+
+	handle, err := windows.CreateFile(filePathP, windows.GENERIC_READ, windows.FILE_SHARE_READ, nil, syscall.CREATE_ALWAYS, 0, 0)
+	if err != nil {
+		t.Fatalf("failed opening held handle on %s: %v", filePath, err)
+	}
+
+	return handle
+}
+
+func TestUnmountVolumeCtxRetriesOnHeldFileHandle(t *testing.T) {
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "mountpoint")
+	if err := os.Mkdir(target, 0); err != nil {
+		t.Fatalf("failed to create %s: %v", target, err)
+	}
+
+	heldHandle := setUpHeldFileHandleInMount(t, tempDir, target)
+	defer windows.CloseHandle(heldHandle)
+
+	opts := UnmountOpts{RetryDeadline: 150 * time.Millisecond}
+
+	start := time.Now()
+	err := UnmountVolumeCtx(context.Background(), target, opts)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatalf("expected UnmountVolumeCtx to fail while a handle is held open on a file under the mount point")
+	}
+	if elapsed < opts.RetryDeadline {
+		t.Errorf("expected UnmountVolumeCtx to retry for at least %v, only took %v", opts.RetryDeadline, elapsed)
+	}
+}
+
+func TestUnmountVolumeCtxRetriesOnHeldHandle(t *testing.T) {
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "mountpoint")
+	if err := os.Mkdir(target, 0); err != nil {
+		t.Fatalf("failed to create %s: %v", target, err)
+	}
+
+	heldHandle := setUpHeldVolumeMount(t, tempDir, target)
+	defer windows.CloseHandle(heldHandle)
+
+	opts := UnmountOpts{ForceDismount: true, RetryDeadline: 150 * time.Millisecond}
+
+	start := time.Now()
+	err := UnmountVolumeCtx(context.Background(), target, opts)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatalf("expected UnmountVolumeCtx to fail while a handle is held open on the volume")
+	}
+	if elapsed < opts.RetryDeadline {
+		t.Errorf("expected UnmountVolumeCtx to retry for at least %v, only took %v", opts.RetryDeadline, elapsed)
+	}
+}
+
+func TestUnmountVolumeCtxDeadlineExceeded(t *testing.T) {
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "mountpoint")
+	if err := os.Mkdir(target, 0); err != nil {
+		t.Fatalf("failed to create %s: %v", target, err)
+	}
+
+	heldHandle := setUpHeldVolumeMount(t, tempDir, target)
+	defer windows.CloseHandle(heldHandle)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := UnmountVolumeCtx(ctx, target, UnmountOpts{ForceDismount: true, RetryDeadline: time.Second})
+	if err == nil {
+		t.Fatalf("expected UnmountVolumeCtx to return once ctx is done")
+	}
+}
@@ -18,9 +18,13 @@
 
 package mount
 
-// Simple wrappers around SetVolumeMountPoint and DeleteVolumeMountPoint
+// Simple wrappers around SetVolumeMountPoint, DeleteVolumeMountPoint, and the
+// NTFS junction (reparse point) equivalents used for binding arbitrary host
+// directories rather than '\\?\Volume{GUID}' sources.
 
 import (
+	"context"
+	"encoding/binary"
 	"path/filepath"
 	"strings"
 	"syscall"
@@ -29,6 +33,22 @@ import (
 	"golang.org/x/sys/windows"
 )
 
+const (
+	fsctlSetReparsePoint    = 0x000900A4
+	fsctlGetReparsePoint    = 0x000900A8
+	fsctlDeleteReparsePoint = 0x000900AC
+
+	ioReparseTagMountPoint = 0xA0000003
+
+	// reparseDataBufferHeaderSize is the size, in bytes, of the common
+	// ReparseTag/ReparseDataLength/Reserved header shared by every
+	// REPARSE_DATA_BUFFER variant.
+	reparseDataBufferHeaderSize = 8
+
+	// maximumReparseDataBufferSize is MAXIMUM_REPARSE_DATA_BUFFER_SIZE.
+	maximumReparseDataBufferSize = 16 * 1024
+)
+
 // Mount volumePath (in format '\\?\Volume{GUID}' at targetPath.
 // https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-setvolumemountpointw
 func setVolumeMountPoint(targetPath string, volumePath string) error {
@@ -57,47 +77,208 @@ func setVolumeMountPoint(targetPath string, volumePath string) error {
 	return nil
 }
 
-// Remove the volume mount at targetPath
+// Remove the volume mount at targetPath. This is a thin wrapper around
+// UnmountVolumeCtx, for callers that don't need cancellation, retry tuning,
+// or force-dismount; see unmount_windows.go.
 // https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-deletevolumemountpointa
 func deleteVolumeMountPoint(targetPath string) error {
-	// Must end in a backslash
-	slashedTarget := filepath.Clean(targetPath) + string(filepath.Separator)
+	return UnmountVolumeCtx(context.Background(), targetPath, UnmountOpts{})
+}
 
-	targetP, err := syscall.UTF16PtrFromString(slashedTarget)
+// setDirectoryJunction binds targetPath to sourcePath as an NTFS junction
+// (reparse point), allowing an arbitrary host directory to be bound rather
+// than a '\\?\Volume{GUID}' source.
+func setDirectoryJunction(targetPath string, sourcePath string) error {
+	sourcePath = filepath.Clean(sourcePath)
+
+	substituteName := `\??\` + sourcePath
+	printName := sourcePath
+
+	reparseBuffer, err := newMountPointReparseBuffer(substituteName, printName)
 	if err != nil {
-		return errors.Wrapf(err, "unable to utf16-ise %s", slashedTarget)
+		return errors.Wrapf(err, "failed building reparse buffer for %s -> %s", targetPath, sourcePath)
 	}
 
-	volumeName, err := getVolumeNameForVolumeMountPoint(targetPath)
+	handle, err := openReparsePoint(targetPath, windows.GENERIC_WRITE)
 	if err != nil {
-		return errors.Wrapf(err, "failed calling getVolumeNameForVolumeMountPoint('%s')", targetPath)
+		return errors.Wrapf(err, "failed opening %s for reparse point creation", targetPath)
 	}
+	defer windows.CloseHandle(handle)
 
-	if err := windows.DeleteVolumeMountPoint(targetP); err != nil {
-		return errors.Wrapf(err, "failed calling DeleteVolumeMountPoint('%s')", slashedTarget)
+	var bytesReturned uint32
+	if err := windows.DeviceIoControl(handle, fsctlSetReparsePoint, &reparseBuffer[0], uint32(len(reparseBuffer)), nil, 0, &bytesReturned, nil); err != nil {
+		return errors.Wrapf(err, "failed calling FSCTL_SET_REPARSE_POINT('%s', '%s')", targetPath, sourcePath)
 	}
 
-	// Strip the trailing slash off for CreaetFile.
-	if volumeName[len(volumeName)-1] == filepath.Separator {
-		volumeName = volumeName[:len(volumeName)-1]
+	return nil
+}
+
+// deleteDirectoryJunction removes the NTFS junction (reparse point) at
+// targetPath.
+func deleteDirectoryJunction(targetPath string) error {
+	handle, err := openReparsePoint(targetPath, windows.GENERIC_WRITE)
+	if err != nil {
+		return errors.Wrapf(err, "failed opening %s for reparse point deletion", targetPath)
 	}
+	defer windows.CloseHandle(handle)
+
+	reparseHeader := struct {
+		ReparseTag        uint32
+		ReparseDataLength uint16
+		Reserved          uint16
+	}{ReparseTag: ioReparseTagMountPoint}
+
+	buffer := make([]byte, reparseDataBufferHeaderSize)
+	binary.LittleEndian.PutUint32(buffer[0:4], reparseHeader.ReparseTag)
 
-	volumeNameP, err := syscall.UTF16PtrFromString(volumeName)
+	var bytesReturned uint32
+	if err := windows.DeviceIoControl(handle, fsctlDeleteReparsePoint, &buffer[0], uint32(len(buffer)), nil, 0, &bytesReturned, nil); err != nil {
+		return errors.Wrapf(err, "failed calling FSCTL_DELETE_REPARSE_POINT('%s')", targetPath)
+	}
+
+	return nil
+}
+
+// isDirectoryJunction reports whether targetPath is bound via an NTFS
+// junction (reparse point) rather than a volume mount point. Both are
+// IO_REPARSE_TAG_MOUNT_POINT reparse points, so FILE_ATTRIBUTE_REPARSE_POINT
+// alone can't tell them apart: a volume mount point's SubstituteName is a
+// '\??\Volume{GUID}' path, while a junction's is an arbitrary NT path, so the
+// reparse data itself has to be read via FSCTL_GET_REPARSE_POINT.
+func isDirectoryJunction(targetPath string) (bool, error) {
+	targetP, err := windows.UTF16PtrFromString(filepath.Clean(targetPath))
 	if err != nil {
-		return errors.Wrapf(err, "unable to utf16-ise %s", volumeName)
+		return false, errors.Wrapf(err, "unable to utf16-ise %s", targetPath)
 	}
 
-	volumeHandle, err := windows.CreateFile(volumeNameP, windows.GENERIC_READ|windows.GENERIC_WRITE, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING, 0, 0)
+	attrs, err := windows.GetFileAttributes(targetP)
 	if err != nil {
-		return errors.Wrapf(err, "failed opening volumeHandle: %s", volumeName)
+		return false, errors.Wrapf(err, "failed calling GetFileAttributes('%s')", targetPath)
 	}
-	defer windows.CloseHandle(volumeHandle)
 
-	if err := windows.FlushFileBuffers(volumeHandle); err != nil {
-		return errors.Wrapf(err, "failed flushing volumeHandle")
+	if attrs&windows.FILE_ATTRIBUTE_REPARSE_POINT == 0 {
+		return false, nil
 	}
 
-	return nil
+	substituteName, err := reparsePointSubstituteName(targetPath)
+	if err != nil {
+		return false, err
+	}
+
+	return !strings.HasPrefix(substituteName, `\??\Volume{`), nil
+}
+
+// reparsePointSubstituteName reads the IO_REPARSE_TAG_MOUNT_POINT reparse
+// point at targetPath via FSCTL_GET_REPARSE_POINT and returns its
+// SubstituteName.
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ca069dad-ed16-42aa-b057-b6b207f447cc
+func reparsePointSubstituteName(targetPath string) (string, error) {
+	handle, err := openReparsePoint(targetPath, windows.GENERIC_READ)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed opening %s for reparse point inspection", targetPath)
+	}
+	defer windows.CloseHandle(handle)
+
+	buffer := make([]byte, maximumReparseDataBufferSize)
+	var bytesReturned uint32
+	if err := windows.DeviceIoControl(handle, fsctlGetReparsePoint, nil, 0, &buffer[0], uint32(len(buffer)), &bytesReturned, nil); err != nil {
+		return "", errors.Wrapf(err, "failed calling FSCTL_GET_REPARSE_POINT('%s')", targetPath)
+	}
+
+	reparseTag := binary.LittleEndian.Uint32(buffer[0:4])
+	if reparseTag != ioReparseTagMountPoint {
+		return "", errors.Errorf("unexpected reparse tag 0x%x for '%s'", reparseTag, targetPath)
+	}
+
+	// MountPointReparseBuffer's fixed fields: SubstituteNameOffset,
+	// SubstituteNameLength, PrintNameOffset, PrintNameLength (4 uint16s).
+	const mountPointBufferFixedFieldsSize = 8
+	pathBuffer := buffer[reparseDataBufferHeaderSize+mountPointBufferFixedFieldsSize:]
+
+	substituteNameOffset := binary.LittleEndian.Uint16(buffer[8:10])
+	substituteNameLength := binary.LittleEndian.Uint16(buffer[10:12])
+
+	substituteNameUTF16 := make([]uint16, substituteNameLength/2)
+	for i := range substituteNameUTF16 {
+		substituteNameUTF16[i] = binary.LittleEndian.Uint16(pathBuffer[int(substituteNameOffset)+i*2:])
+	}
+
+	return windows.UTF16ToString(substituteNameUTF16), nil
+}
+
+// openReparsePoint opens targetPath with the flags required to manipulate a
+// reparse point: FILE_FLAG_OPEN_REPARSE_POINT and FILE_FLAG_BACKUP_SEMANTICS
+// (the latter is required to open a directory handle at all).
+func openReparsePoint(targetPath string, access uint32) (windows.Handle, error) {
+	targetP, err := windows.UTF16PtrFromString(filepath.Clean(targetPath))
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to utf16-ise %s", targetPath)
+	}
+
+	handle, err := windows.CreateFile(
+		targetP,
+		access,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_EXISTING,
+		windows.FILE_FLAG_OPEN_REPARSE_POINT|windows.FILE_FLAG_BACKUP_SEMANTICS,
+		0)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed calling CreateFile('%s')", targetPath)
+	}
+
+	return handle, nil
+}
+
+// newMountPointReparseBuffer builds a REPARSE_DATA_BUFFER of type
+// IO_REPARSE_TAG_MOUNT_POINT (a MountPointReparseBuffer) suitable for passing
+// to FSCTL_SET_REPARSE_POINT.
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ca069dad-ed16-42aa-b057-b6b207f447cc
+func newMountPointReparseBuffer(substituteName string, printName string) ([]byte, error) {
+	substituteNameP, err := syscall.UTF16FromString(substituteName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to utf16-ise %s", substituteName)
+	}
+	printNameP, err := syscall.UTF16FromString(printName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to utf16-ise %s", printName)
+	}
+
+	// Exclude each slice's NUL terminator from the on-the-wire lengths, but
+	// keep both NULs in the path buffer itself, as MS-FSCC requires.
+	substituteNameBytes := len(substituteNameP)*2 - 2
+	printNameBytes := len(printNameP)*2 - 2
+
+	// MountPointReparseBuffer's fixed fields: SubstituteNameOffset,
+	// SubstituteNameLength, PrintNameOffset, PrintNameLength (4 uint16s).
+	const mountPointBufferFixedFieldsSize = 8
+
+	pathBuffer := make([]byte, substituteNameBytes+2+printNameBytes+2)
+	for i, c := range substituteNameP {
+		binary.LittleEndian.PutUint16(pathBuffer[i*2:], c)
+	}
+	printNameOffset := substituteNameBytes + 2
+	for i, c := range printNameP {
+		binary.LittleEndian.PutUint16(pathBuffer[printNameOffset+i*2:], c)
+	}
+
+	reparseDataLength := mountPointBufferFixedFieldsSize + len(pathBuffer)
+	totalSize := reparseDataBufferHeaderSize + reparseDataLength
+	if totalSize > maximumReparseDataBufferSize {
+		return nil, errors.Errorf("reparse data buffer of %d bytes exceeds MAXIMUM_REPARSE_DATA_BUFFER_SIZE", totalSize)
+	}
+
+	buffer := make([]byte, totalSize)
+	binary.LittleEndian.PutUint32(buffer[0:4], ioReparseTagMountPoint)
+	binary.LittleEndian.PutUint16(buffer[4:6], uint16(reparseDataLength))
+	// buffer[6:8] is the Reserved field, left zeroed.
+	binary.LittleEndian.PutUint16(buffer[8:10], 0)
+	binary.LittleEndian.PutUint16(buffer[10:12], uint16(substituteNameBytes))
+	binary.LittleEndian.PutUint16(buffer[12:14], uint16(printNameOffset))
+	binary.LittleEndian.PutUint16(buffer[14:16], uint16(printNameBytes))
+	copy(buffer[reparseDataBufferHeaderSize+mountPointBufferFixedFieldsSize:], pathBuffer)
+
+	return buffer, nil
 }
 
 // getVolumeNameForVolumeMountPoint returns a volume path (in format '\\?\Volume{GUID}'
@@ -124,3 +305,172 @@ func getVolumeNameForVolumeMountPoint(targetPath string) (string, error) {
 
 	return windows.UTF16ToString(buffer), nil
 }
+
+// ListVolumeMountPoints returns every path (drive letter or folder) at which
+// the volume identified by volumePath (in '\\?\Volume{GUID}\' format) is
+// currently mounted.
+func ListVolumeMountPoints(volumePath string) ([]string, error) {
+	return listVolumeMountPoints(volumePath)
+}
+
+// listVolumeMountPoints enumerates every path (drive letter or folder) at
+// which volumePath (in format '\\?\Volume{GUID}') is currently mounted, via
+// GetVolumePathNamesForVolumeNameW.
+//
+// This is deliberately not FindFirstVolumeMountPointW/FindNextVolumeMountPointW:
+// those enumerate mount points nested *on* the given volume (i.e. other
+// volumes mounted in subfolders of it), not the paths at which the given
+// volume is itself mounted, which is what callers resolving a volume GUID
+// back to a usable path need.
+// https://docs.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-getvolumepathnamesforvolumenamew
+func listVolumeMountPoints(volumePath string) ([]string, error) {
+	if !strings.HasPrefix(volumePath, "\\\\?\\Volume{") {
+		return nil, errors.Errorf("unable to enumerate mount points for non-volume path %s", volumePath)
+	}
+
+	slashedVolume := filepath.Clean(volumePath) + string(filepath.Separator)
+
+	volumeP, err := windows.UTF16PtrFromString(slashedVolume)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to utf16-ise %s", slashedVolume)
+	}
+
+	// "A reasonable size for the buffer" per the documentation, grown below
+	// to the size GetVolumePathNamesForVolumeNameW reports it actually needs.
+	bufferlength := uint32(50)
+	buffer := make([]uint16, bufferlength)
+
+	for {
+		var returnLength uint32
+		err := windows.GetVolumePathNamesForVolumeName(volumeP, &buffer[0], bufferlength, &returnLength)
+		if err == windows.ERROR_MORE_DATA {
+			bufferlength = returnLength
+			buffer = make([]uint16, bufferlength)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed calling GetVolumePathNamesForVolumeNameW('%s', ..., %d)", slashedVolume, bufferlength)
+		}
+
+		return splitMultiString(buffer[:returnLength]), nil
+	}
+}
+
+// splitMultiString splits buf, a sequence of NUL-terminated UTF-16 strings
+// itself terminated by an empty string (as returned by
+// GetVolumePathNamesForVolumeNameW), into its component strings.
+func splitMultiString(buf []uint16) []string {
+	var strs []string
+	start := 0
+	for i, c := range buf {
+		if c != 0 {
+			continue
+		}
+		if i > start {
+			strs = append(strs, windows.UTF16ToString(buf[start:i]))
+		}
+		start = i + 1
+	}
+	return strs
+}
+
+// maxPath is MAX_PATH, "a reasonable size for the buffer" per the
+// documentation for both GetVolumeInformationW's volume-name buffer and its
+// file-system-name buffer.
+const maxPath = 260
+
+// VolumeInfo carries the metadata GetVolumeInformationW and
+// GetDiskFreeSpaceExW report for the volume backing a mount target.
+type VolumeInfo struct {
+	// VolumeLabel is the user-assigned volume label, e.g. "System".
+	VolumeLabel string
+	// FileSystemName is the filesystem driver name, e.g. "NTFS" or "ReFS".
+	FileSystemName string
+	// SerialNumber is the volume serial number assigned at format time.
+	SerialNumber uint32
+	// MaximumComponentLength is the maximum length, in characters, of a
+	// single path component supported by the filesystem.
+	MaximumComponentLength uint32
+	// FileSystemFlags holds the FILE_SUPPORTS_*/FILE_*_VOLUME bits reported
+	// for the filesystem, e.g. FileSupportsReparsePoints.
+	FileSystemFlags uint32
+	// FreeBytesAvailable is the number of bytes available to the caller,
+	// which may be less than TotalFreeBytes if per-user quotas apply.
+	FreeBytesAvailable uint64
+	// TotalBytes is the total size of the volume, in bytes.
+	TotalBytes uint64
+	// TotalFreeBytes is the total number of free bytes on the volume.
+	TotalFreeBytes uint64
+}
+
+// Filesystem flags returned in VolumeInfo.FileSystemFlags by
+// GetVolumeInformationW; see
+// https://docs.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-getvolumeinformationw
+const (
+	FileSupportsReparsePoints = windows.FILE_SUPPORTS_REPARSE_POINTS
+	FileReadOnlyVolume        = windows.FILE_READ_ONLY_VOLUME
+	FileSupportsHardLinks     = windows.FILE_SUPPORTS_HARD_LINKS
+)
+
+// VolumeInformation resolves the volume backing targetPath and returns its
+// label, filesystem, serial number, maximum component length, filesystem
+// flags, and free/total space.
+func VolumeInformation(targetPath string) (VolumeInfo, error) {
+	targetP, err := windows.UTF16PtrFromString(targetPath)
+	if err != nil {
+		return VolumeInfo{}, errors.Wrapf(err, "unable to utf16-ise %s", targetPath)
+	}
+
+	// targetPath need not itself be a mount point, so find the mount point
+	// that contains it first.
+	mountPointBuffer := make([]uint16, len(targetPath)+maxPath+1)
+	if err := windows.GetVolumePathName(targetP, &mountPointBuffer[0], uint32(len(mountPointBuffer))); err != nil {
+		return VolumeInfo{}, errors.Wrapf(err, "failed calling GetVolumePathNameW('%s')", targetPath)
+	}
+	mountPoint := windows.UTF16ToString(mountPointBuffer)
+
+	volumeName, err := getVolumeNameForVolumeMountPoint(mountPoint)
+	if err != nil {
+		return VolumeInfo{}, errors.Wrapf(err, "failed calling getVolumeNameForVolumeMountPoint('%s')", mountPoint)
+	}
+
+	volumeP, err := windows.UTF16PtrFromString(volumeName)
+	if err != nil {
+		return VolumeInfo{}, errors.Wrapf(err, "unable to utf16-ise %s", volumeName)
+	}
+
+	var info VolumeInfo
+	var volumeNameBuffer, fileSystemNameBuffer []uint16
+	volumeNameLength, fileSystemNameLength := uint32(maxPath+1), uint32(maxPath+1)
+
+	for {
+		volumeNameBuffer = make([]uint16, volumeNameLength)
+		fileSystemNameBuffer = make([]uint16, fileSystemNameLength)
+
+		err = windows.GetVolumeInformation(
+			volumeP,
+			&volumeNameBuffer[0], volumeNameLength,
+			&info.SerialNumber,
+			&info.MaximumComponentLength,
+			&info.FileSystemFlags,
+			&fileSystemNameBuffer[0], fileSystemNameLength)
+		if err == windows.ERROR_FILENAME_EXCED_RANGE || err == windows.ERROR_MORE_DATA {
+			volumeNameLength *= 2
+			fileSystemNameLength *= 2
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return VolumeInfo{}, errors.Wrapf(err, "failed calling GetVolumeInformationW('%s')", volumeName)
+	}
+
+	info.VolumeLabel = windows.UTF16ToString(volumeNameBuffer)
+	info.FileSystemName = windows.UTF16ToString(fileSystemNameBuffer)
+
+	if err := windows.GetDiskFreeSpaceEx(volumeP, &info.FreeBytesAvailable, &info.TotalBytes, &info.TotalFreeBytes); err != nil {
+		return VolumeInfo{}, errors.Wrapf(err, "failed calling GetDiskFreeSpaceExW('%s')", volumeName)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,151 @@
+// +build windows
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListVolumeMountPoints(t *testing.T) {
+	tempDir := t.TempDir()
+
+	volumeName, err := getVolumeNameForVolumeMountPoint(filepath.VolumeName(tempDir) + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("getVolumeNameForVolumeMountPoint(%s) failed: %v", tempDir, err)
+	}
+
+	var targets []string
+	for i := 0; i < 3; i++ {
+		target := filepath.Join(tempDir, fmt.Sprintf("mountpoint%d", i))
+		if err := os.Mkdir(target, 0); err != nil {
+			t.Fatalf("failed to create %s: %v", target, err)
+		}
+
+		if err := setVolumeMountPoint(target, volumeName); err != nil {
+			t.Skipf("setVolumeMountPoint(%s, %s) failed, this test requires admin privileges: %v", target, volumeName, err)
+		}
+		defer deleteVolumeMountPoint(target)
+
+		targets = append(targets, filepath.Clean(target)+string(filepath.Separator))
+	}
+
+	mountPoints, err := listVolumeMountPoints(volumeName)
+	if err != nil {
+		t.Fatalf("listVolumeMountPoints(%s) failed: %v", volumeName, err)
+	}
+
+	for _, target := range targets {
+		found := false
+		for _, mountPoint := range mountPoints {
+			if mountPoint == target {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected %s to be included in %v", target, mountPoints)
+		}
+	}
+}
+
+func TestDirectoryJunctionRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	source := filepath.Join(tempDir, "source")
+	target := filepath.Join(tempDir, "target")
+
+	if err := os.Mkdir(source, 0); err != nil {
+		t.Fatalf("failed to create %s: %v", source, err)
+	}
+	if err := os.Mkdir(target, 0); err != nil {
+		t.Fatalf("failed to create %s: %v", target, err)
+	}
+
+	if err := setDirectoryJunction(target, source); err != nil {
+		t.Fatalf("setDirectoryJunction(%s, %s) failed: %v", target, source, err)
+	}
+	defer deleteDirectoryJunction(target)
+
+	isJunction, err := isDirectoryJunction(target)
+	if err != nil {
+		t.Fatalf("isDirectoryJunction(%s) failed: %v", target, err)
+	}
+	if !isJunction {
+		t.Errorf("expected %s to be reported as a directory junction", target)
+	}
+
+	if err := deleteDirectoryJunction(target); err != nil {
+		t.Fatalf("deleteDirectoryJunction(%s) failed: %v", target, err)
+	}
+
+	isJunction, err = isDirectoryJunction(target)
+	if err != nil {
+		t.Fatalf("isDirectoryJunction(%s) failed after delete: %v", target, err)
+	}
+	if isJunction {
+		t.Errorf("expected %s to no longer be a directory junction after delete", target)
+	}
+}
+
+func TestIsDirectoryJunctionDistinguishesVolumeMountPoint(t *testing.T) {
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "mountpoint")
+	if err := os.Mkdir(target, 0); err != nil {
+		t.Fatalf("failed to create %s: %v", target, err)
+	}
+
+	volumeName, err := getVolumeNameForVolumeMountPoint(filepath.VolumeName(tempDir) + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("getVolumeNameForVolumeMountPoint(%s) failed: %v", tempDir, err)
+	}
+
+	if err := setVolumeMountPoint(target, volumeName); err != nil {
+		t.Skipf("setVolumeMountPoint(%s, %s) failed, this test requires admin privileges: %v", target, volumeName, err)
+	}
+	defer deleteVolumeMountPoint(target)
+
+	isJunction, err := isDirectoryJunction(target)
+	if err != nil {
+		t.Fatalf("isDirectoryJunction(%s) failed: %v", target, err)
+	}
+	if isJunction {
+		t.Errorf("expected a real volume mount point to not be reported as a directory junction")
+	}
+}
+
+func TestVolumeInformation(t *testing.T) {
+	tempDir := t.TempDir()
+
+	info, err := VolumeInformation(tempDir)
+	if err != nil {
+		t.Fatalf("VolumeInformation(%s) failed: %v", tempDir, err)
+	}
+
+	if info.FileSystemName == "" {
+		t.Errorf("expected a non-empty FileSystemName")
+	}
+	if info.TotalBytes == 0 {
+		t.Errorf("expected a non-zero TotalBytes")
+	}
+	if info.FileSystemFlags&FileSupportsReparsePoints == 0 {
+		t.Errorf("expected the temp volume to report FileSupportsReparsePoints")
+	}
+}